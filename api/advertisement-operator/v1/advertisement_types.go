@@ -0,0 +1,61 @@
+package v1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NetworkInfo describes how to reach the cluster that published an Advertisement.
+type NetworkInfo struct {
+	// PodCIDR is the pod network CIDR of the advertising cluster.
+	PodCIDR string `json:"podCIDR,omitempty"`
+	// GatewayIP is the IP address of the advertising cluster's gateway node.
+	GatewayIP string `json:"gatewayIP,omitempty"`
+	// GatewayPrivateIP is the private IP address of the advertising cluster's gateway node.
+	GatewayPrivateIP string `json:"gatewayPrivateIP,omitempty"`
+	// SupportedProtocols lists the tunneling protocols the gateway accepts, most preferred first.
+	SupportedProtocols []string `json:"supportedProtocols,omitempty"`
+}
+
+// AdvertisementSpec offers a cluster's spare capacity to a foreign peer.
+type AdvertisementSpec struct {
+	// ClusterId is the cluster ID of the advertising cluster (must be a UUID).
+	ClusterId string `json:"clusterId"`
+	// PeerIdentity is the PEM-encoded public certificate identifying the advertising cluster, so
+	// the receiving cluster's admission webhook can pin or rotate it independently of whatever
+	// kubeconfig was used to reach its API server.
+	PeerIdentity string `json:"peerIdentity,omitempty"`
+	// Images lists the container images already pulled on the advertising cluster's nodes.
+	Images []corev1.ContainerImage `json:"images,omitempty"`
+	// Availability is the advertising cluster's free CPU, memory, ephemeral-storage and pods.
+	Availability corev1.ResourceList `json:"availability,omitempty"`
+	// LimitRange constrains the resources a pod scheduled on behalf of this advertisement may use.
+	LimitRange corev1.LimitRangeSpec `json:"limitRange,omitempty"`
+	// Prices gives, per resource and per image, the cost of consuming this advertisement.
+	Prices corev1.ResourceList `json:"prices,omitempty"`
+	// Network describes how to reach the advertising cluster.
+	Network NetworkInfo `json:"network,omitempty"`
+	// Timestamp is when this advertisement was generated.
+	Timestamp metav1.Time `json:"timestamp,omitempty"`
+	// TimeToLive is when this advertisement should be considered stale and ignored.
+	TimeToLive metav1.Time `json:"timeToLive,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// Advertisement is the Schema for the advertisements API.
+type Advertisement struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec AdvertisementSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// AdvertisementList contains a list of Advertisement.
+type AdvertisementList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Advertisement `json:"items"`
+}
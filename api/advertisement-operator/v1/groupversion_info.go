@@ -0,0 +1,25 @@
+// Package v1 contains the protocol.liqo.io/v1 API, exchanged between the advertisement
+// broadcasters of two peered clusters: an Advertisement is how a cluster offers its spare
+// capacity to a foreign one.
+// +kubebuilder:object:generate=true
+package v1
+
+import (
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/scheme"
+)
+
+var (
+	// GroupVersion is the group/version used to register these objects.
+	GroupVersion = schema.GroupVersion{Group: "protocol.liqo.io", Version: "v1"}
+
+	// SchemeBuilder is used to add go types to the GroupVersionKind scheme.
+	SchemeBuilder = &scheme.Builder{GroupVersion: GroupVersion}
+
+	// AddToScheme adds the types in this group-version to the given scheme.
+	AddToScheme = SchemeBuilder.AddToScheme
+)
+
+func init() {
+	SchemeBuilder.Register(&Advertisement{}, &AdvertisementList{})
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/clientcmd"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+
+	protocolv1 "github.com/netgroup-polito/dronev2/api/advertisement-operator/v1"
+	advertisement_operator "github.com/netgroup-polito/dronev2/internal/advertisement-operator"
+	pkg "github.com/netgroup-polito/dronev2/pkg/advertisement-operator"
+	"github.com/netgroup-polito/dronev2/pkg/advertisement-operator/bootstrapper"
+)
+
+func main() {
+	var (
+		clusterId         string
+		localKubeconfig   string
+		foreignKubeconfig string
+		leaseNamespace    string
+		tlsCertFile       string
+		tlsKeyFile        string
+		certDir           string
+		gatewayIP         string
+		gatewayPrivateIP  string
+
+		bootstrapperName string
+		peersSecretName  string
+		mdnsDomain       string
+		mdnsPort         int
+		bootstrapToken   string
+
+		enableWebhook bool
+		webhookPort   int
+	)
+
+	flag.StringVar(&clusterId, "cluster-id", "", "the cluster ID of this cluster (must be a UUID)")
+	flag.StringVar(&localKubeconfig, "local-kubeconfig", "", "path to the local cluster kubeconfig, for out-of-cluster debugging")
+	flag.StringVar(&foreignKubeconfig, "foreign-kubeconfig", "", "path to a foreign cluster kubeconfig, for out-of-cluster debugging")
+	flag.StringVar(&leaseNamespace, "lease-namespace", "default", "namespace holding the Lease used to elect the broadcaster leader")
+	flag.StringVar(&tlsCertFile, "tls-cert-file", "", "path to this cluster's TLS certificate; leave unset together with --tls-key-file to generate and persist a self-signed one")
+	flag.StringVar(&tlsKeyFile, "tls-key-file", "", "path to this cluster's TLS private key; leave unset together with --tls-cert-file to generate and persist a self-signed one")
+	flag.StringVar(&certDir, "cert-dir", pkg.DefaultCertDir, "where a generated self-signed keypair is persisted")
+	flag.StringVar(&gatewayIP, "gateway-ip", "", "the IP address of the gateway node")
+	flag.StringVar(&gatewayPrivateIP, "gateway-private-ip", "", "the private IP address of the gateway node")
+
+	flag.StringVar(&bootstrapperName, "bootstrapper", "", `how to discover and join peer clusters: "static", "mdns", or empty to rely only on pre-existing foreign-kubeconfig ConfigMaps`)
+	flag.StringVar(&peersSecretName, "static-peers-secret", "liqo-peers", `name of the Secret (in "default") listing peers, used when --bootstrapper=static`)
+	flag.StringVar(&mdnsDomain, "mdns-domain", "local.", `mDNS domain to browse/announce on, used when --bootstrapper=mdns`)
+	flag.IntVar(&mdnsPort, "mdns-port", 6443, "this cluster's own API server port to announce via mDNS, used when --bootstrapper=mdns")
+	flag.StringVar(&bootstrapToken, "bootstrap-token", "", "bearer token a discovering peer uses against this cluster's API server to complete Join, announced via --bootstrapper=mdns; must already be bound to a role allowed to create the liqo-advertisement-bootstrap ServiceAccount/Role/RoleBinding")
+	flag.BoolVar(&enableWebhook, "enable-webhook", false, "run the PeerIdentity-pinning admission webhook validating incoming Advertisements")
+	flag.IntVar(&webhookPort, "webhook-port", 9443, "port the admission webhook server listens on, used when --enable-webhook")
+	flag.Parse()
+
+	log := ctrl.Log.WithName("advertisement-broadcaster")
+
+	if enableWebhook {
+		// make sure this cluster's serving keypair exists before the webhook manager starts, so
+		// runWebhookServer can point its CertDir at the exact same files InitializeTLS persists -
+		// it would otherwise serve HTTPS with no certificate at all
+		if _, _, err := pkg.InitializeTLS(certDir, tlsCertFile, tlsKeyFile, clusterId); err != nil {
+			log.Error(err, "Unable to initialize TLS identity for the webhook server")
+			os.Exit(1)
+		}
+		go runWebhookServer(certDir, tlsCertFile, tlsKeyFile, localKubeconfig, webhookPort, log)
+	}
+
+	if bootstrapperName != "" {
+		localClient, err := pkg.NewK8sClient(localKubeconfig, nil)
+		if err != nil {
+			log.Error(err, "Unable to create client to local cluster")
+			os.Exit(1)
+		}
+
+		bs, err := newBootstrapper(bootstrapperName, localClient, peersSecretName, mdnsDomain)
+		if err != nil {
+			log.Error(err, "Unable to initialize bootstrapper")
+			os.Exit(1)
+		}
+
+		if announcer, ok := bs.(bootstrapper.Announcer); ok {
+			go runAnnounceLoop(context.Background(), log, announcer, certDir, tlsCertFile, tlsKeyFile, clusterId, bootstrapToken, mdnsPort)
+		}
+
+		go runBootstrapLoop(context.Background(), log, bs, localClient)
+	}
+
+	advertisement_operator.StartBroadcaster(clusterId, localKubeconfig, foreignKubeconfig, leaseNamespace, tlsCertFile, tlsKeyFile, certDir, gatewayIP, gatewayPrivateIP)
+}
+
+func newBootstrapper(name string, localClient *kubernetes.Clientset, peersSecretName string, mdnsDomain string) (bootstrapper.Bootstrapper, error) {
+	switch name {
+	case "static":
+		return bootstrapper.NewStatic(localClient, "default", peersSecretName), nil
+	case "mdns":
+		return bootstrapper.NewMDNS(mdnsDomain, 5*time.Second), nil
+	default:
+		return nil, fmt.Errorf("unknown bootstrapper %q", name)
+	}
+}
+
+// runBootstrapLoop periodically discovers and joins new peer candidates, materializing the
+// foreign-kubeconfig ConfigMap that the broadcaster's informer picks up; it is the only glue
+// between a Bootstrapper and StartBroadcaster, which stays unaware of how peers were onboarded.
+//
+// joined remembers which cluster IDs have already been joined, so a steady-state deployment
+// doesn't re-Join the same peer on every tick.
+func runBootstrapLoop(ctx context.Context, log logr.Logger, bs bootstrapper.Bootstrapper, localClient *kubernetes.Clientset) {
+	joined := make(map[string]bool)
+
+	for {
+		candidates, err := bs.Discover(ctx)
+		if err != nil {
+			log.Error(err, "Unable to discover peer candidates")
+		}
+
+		for _, candidate := range candidates {
+			if joined[candidate.ClusterID] {
+				continue
+			}
+
+			cm, err := bs.Join(ctx, candidate)
+			if err != nil {
+				log.Error(err, "Unable to join peer candidate "+candidate.ClusterID)
+				continue
+			}
+			if err := createOrUpdateConfigMap(localClient, cm); err != nil {
+				log.Error(err, "Unable to persist foreign-kubeconfig ConfigMap for "+candidate.ClusterID)
+				continue
+			}
+			joined[candidate.ClusterID] = true
+		}
+
+		select {
+		case <-time.After(time.Minute):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// runAnnounceLoop publishes this cluster's own bootstrap service through announcer, so a peer
+// running the same discovery mechanism (e.g. another mDNS bootstrapper on the same LAN) can find
+// it in turn instead of discovery only ever working one-directionally. It blocks until ctx is
+// cancelled, retrying with a fixed backoff if announcer.Announce itself returns early with an
+// error (e.g. the mDNS service could not be registered).
+func runAnnounceLoop(ctx context.Context, log logr.Logger, announcer bootstrapper.Announcer, certDir string, tlsCertFile string, tlsKeyFile string, clusterId string, bootstrapToken string, port int) {
+	_, peerIdentityPEM, err := pkg.InitializeTLS(certDir, tlsCertFile, tlsKeyFile, clusterId)
+	if err != nil {
+		log.Error(err, "Unable to initialize TLS identity for mDNS announce")
+		return
+	}
+
+	fingerprint, err := bootstrapper.FingerprintPEM(string(peerIdentityPEM))
+	if err != nil {
+		log.Error(err, "Unable to compute certificate fingerprint for mDNS announce")
+		return
+	}
+
+	for {
+		if err := announcer.Announce(ctx, clusterId, bootstrapToken, fingerprint, port); err != nil {
+			log.Error(err, "mDNS announce exited with an error, retrying")
+		}
+
+		select {
+		case <-time.After(time.Minute):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// runWebhookServer runs the manager backing the PeerIdentityValidator admission webhook, serving
+// it over the same self-signed keypair InitializeTLS persists for this cluster's peer identity -
+// without a serving certificate under the webhook server's CertDir, mgr.Start would fail to serve
+// HTTPS at all, and the webhook's ValidatingWebhookConfiguration (config/webhook/manifests.yaml)
+// would have no server able to answer requests forwarded to it.
+func runWebhookServer(certDir string, tlsCertFile string, tlsKeyFile string, kubeconfig string, port int, log logr.Logger) {
+	restConfig, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+	if err != nil {
+		log.Error(err, "Unable to build REST config for the webhook server")
+		return
+	}
+
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = protocolv1.AddToScheme(scheme)
+
+	servingCertFile, servingKeyFile := pkg.ResolveCertPaths(certDir, tlsCertFile, tlsKeyFile)
+
+	mgr, err := ctrl.NewManager(restConfig, ctrl.Options{
+		Scheme:             scheme,
+		Port:               port,
+		CertDir:            filepath.Dir(servingCertFile),
+		MetricsBindAddress: "0",
+	})
+	if err != nil {
+		log.Error(err, "Unable to start the webhook manager")
+		return
+	}
+
+	webhookServer := mgr.GetWebhookServer()
+	webhookServer.CertName = filepath.Base(servingCertFile)
+	webhookServer.KeyName = filepath.Base(servingKeyFile)
+	webhookServer.Register("/validate-advertisement", &webhook.Admission{
+		Handler: &advertisement_operator.PeerIdentityValidator{Client: mgr.GetClient()},
+	})
+
+	if err := mgr.Start(context.Background()); err != nil {
+		log.Error(err, "Webhook manager exited with an error")
+	}
+}
+
+func createOrUpdateConfigMap(localClient *kubernetes.Clientset, cm *corev1.ConfigMap) error {
+	_, err := localClient.CoreV1().ConfigMaps(cm.Namespace).Create(cm)
+	if apierrors.IsAlreadyExists(err) {
+		_, err = localClient.CoreV1().ConfigMaps(cm.Namespace).Update(cm)
+	}
+	return err
+}
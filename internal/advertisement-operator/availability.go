@@ -0,0 +1,64 @@
+package advertisement_operator
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// availabilityCache holds the cluster-wide availability, images and pod CIDR computed once per
+// tick and shared by every peer's GenerateAdvertisement goroutine, so that N configured peers
+// don't each repeat the same Nodes().List and per-node Pods().List calls every tick for data that
+// is identical across peers.
+type availabilityCache struct {
+	mutex        sync.RWMutex
+	availability corev1.ResourceList
+	images       []corev1.ContainerImage
+	podCIDR      string
+}
+
+func newAvailabilityCache() *availabilityCache {
+	return &availabilityCache{}
+}
+
+// get returns the most recently computed availability, images and pod CIDR.
+func (c *availabilityCache) get() (corev1.ResourceList, []corev1.ContainerImage, string) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.availability, c.images, c.podCIDR
+}
+
+// refresh recomputes availability, images and pod CIDR from the local cluster's current state.
+func (c *availabilityCache) refresh(localClient *kubernetes.Clientset) error {
+	nodes, err := localClient.CoreV1().Nodes().List(metav1.ListOptions{LabelSelector: "type != virtual-node"})
+	if err != nil {
+		return err
+	}
+
+	availability, images := GetClusterResources(localClient, nodes.Items)
+	podCIDR := GetPodCIDR(nodes.Items)
+
+	c.mutex.Lock()
+	c.availability, c.images, c.podCIDR = availability, images, podCIDR
+	c.mutex.Unlock()
+	return nil
+}
+
+// runAvailabilityRefresher keeps cache up to date every 10 minutes until ctx is cancelled; the
+// caller is expected to have populated cache with an initial refresh beforehand.
+func runAvailabilityRefresher(ctx context.Context, localClient *kubernetes.Clientset, cache *availabilityCache) {
+	for {
+		select {
+		case <-time.After(10 * time.Minute):
+		case <-ctx.Done():
+			return
+		}
+		if err := cache.refresh(localClient); err != nil {
+			log.Error(err, "Unable to refresh cluster availability")
+		}
+	}
+}
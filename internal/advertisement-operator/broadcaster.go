@@ -14,29 +14,45 @@ import (
 
 	protocolv1 "github.com/netgroup-polito/dronev2/api/advertisement-operator/v1"
 	pkg "github.com/netgroup-polito/dronev2/pkg/advertisement-operator"
+	"github.com/netgroup-polito/dronev2/pkg/advertisement-operator/bootstrapper"
+	"github.com/netgroup-polito/dronev2/pkg/advertisement-operator/leaderelection"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
 	resourcehelper "k8s.io/kubectl/pkg/util/resource"
+	"os"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+// foreignKubeconfigLabel marks the ConfigMaps that carry a foreign cluster's kubeconfig and
+// that the broadcaster's peer informer should watch; it is the same label a Bootstrapper.Join
+// sets on the ConfigMaps it materializes
+const foreignKubeconfigLabel = bootstrapper.ForeignKubeconfigLabel
+
 var (
 	log logr.Logger
 )
 
 // start the broadcaster which sends Advertisement messages
 // it reads the ConfigMaps to get the kubeconfigs to the remote clusters and create a client for each of them
+// only the elected leader actually broadcasts: this lets an HA deployment run several
+// replicas without producing duplicate Advertisement objects on every peer
 // parameters
 // - clusterId: the cluster ID of your cluster (must be a UUID)
 // - localKubeconfig: the path to the kubeconfig of the local cluster. Set it only when you are debugging and need to launch the program as a process and not inside Kubernetes
 // - foreignKubeconfig: the path to the kubeconfig of the foreign cluster. Set it only when you are debugging and need to launch the program as a process and not inside Kubernetes
+// - leaseNamespace: the namespace holding the Lease used to elect the broadcaster leader
+// - tlsCertFile/tlsKeyFile: paths to this cluster's TLS identity. Leave both empty to have a
+//   self-signed ECDSA keypair generated and persisted under certDir on first start
+// - certDir: where a generated keypair is persisted (pkg.DefaultCertDir if empty)
 // - gatewayIP: the IP address of the gateway node
 // - gatewayPrivateIP: the private IP address of the gateway node
-func StartBroadcaster(clusterId string, localKubeconfig string, foreignKubeconfig string, gatewayIP string, gatewayPrivateIP string) {
+func StartBroadcaster(clusterId string, localKubeconfig string, foreignKubeconfig string, leaseNamespace string, tlsCertFile string, tlsKeyFile string, certDir string, gatewayIP string, gatewayPrivateIP string) {
 	log = ctrl.Log.WithName("advertisement-broadcaster")
 	log.Info("starting broadcaster")
 
@@ -53,37 +69,174 @@ func StartBroadcaster(clusterId string, localKubeconfig string, foreignKubeconfi
 		return
 	}
 
-	// get configMaps containing the kubeconfig of the foreign clusters
-	configMaps, err := localClient.CoreV1().ConfigMaps("default").List(metav1.ListOptions{})
+	// this cluster's identity, published in every Advertisement as Spec.PeerIdentity so a
+	// receiving cluster's admission webhook can pin or rotate it independently of whatever
+	// kubeconfig was used to reach the API server.
+	//
+	// IMPORTANT for HA deployments (several replicas behind leaderelection.Run): certDir must be
+	// storage shared across every replica (e.g. a ReadWriteMany volume), not the pod-local default.
+	// Each replica calls InitializeTLS independently, and whichever one currently holds leadership
+	// is the one whose identity actually gets published; if certDir is pod-local, a leader failover
+	// silently switches to a different self-signed identity, and every peer's trust bundle pinned
+	// to the old one starts rejecting this cluster's advertisements. warnIfIdentityChanged below
+	// cannot prevent that, only make it loud instead of silent.
+	_, peerIdentityPEM, err := pkg.InitializeTLS(certDir, tlsCertFile, tlsKeyFile, clusterId)
+	if err != nil {
+		log.Error(err, "Unable to initialize TLS identity")
+		return
+	}
+	peerIdentity := string(peerIdentityPEM)
+	warnIfIdentityChanged(localClient, leaseNamespace, clusterId, peerIdentity)
+
+	// the election identity must differ between replicas, and clusterId is shared by all of them;
+	// the hostname (the pod name, inside Kubernetes) is unique per replica
+	identity, err := os.Hostname()
 	if err != nil {
-		log.Error(err, "Unable to list configMaps")
+		log.Error(err, "Unable to determine leader election identity")
 		return
 	}
 
-	var wg sync.WaitGroup
-	// during operation the foreignKubeconfigs are taken from the ConfigMaps
-	for _, cm := range configMaps.Items {
-		if strings.HasPrefix(cm.Name, "foreign-kubeconfig") {
-			wg.Add(1)
-			go GenerateAdvertisement(&wg, localClient, localCRDClient, foreignKubeconfig, cm.DeepCopy(), clusterId, gatewayIP, gatewayPrivateIP)
+	leCfg := leaderelection.Config{
+		LeaseNamespace: leaseNamespace,
+		LeaseName:      "liqo-advertisement-broadcaster",
+		Identity:       identity,
+		Client:         localClient,
+		Log:            log,
+	}
+
+	err = leaderelection.Run(leCfg,
+		func(ctx context.Context) {
+			log.Info("acquired leadership, starting to broadcast advertisements")
+			runBroadcaster(ctx, localClient, localCRDClient, foreignKubeconfig, clusterId, peerIdentity, gatewayIP, gatewayPrivateIP)
+		},
+		func() {
+			log.Info("lost leadership, stopping advertisement broadcast")
+		},
+	)
+	if err != nil {
+		log.Error(err, "Unable to start leader election")
+	}
+}
+
+// lastIdentityConfigMapPrefix names the ConfigMap recording the fingerprint of the identity a
+// broadcaster replica most recently started up with, so the next replica to start (e.g. a standby
+// taking over after a leader failover) can tell whether it is about to publish a different one.
+const lastIdentityConfigMapPrefix = "advertisement-last-identity-"
+
+// warnIfIdentityChanged compares peerIdentity's fingerprint against the one the previous
+// broadcaster replica recorded for clusterId and logs loudly if they differ, then records the
+// current one for next time. It cannot stop the identity from changing across a leader failover
+// when certDir is pod-local rather than shared storage (see the warning on InitializeTLS's call
+// site above); it only turns that failure mode from a silent trap into an observable log line.
+func warnIfIdentityChanged(localClient *kubernetes.Clientset, namespace string, clusterId string, peerIdentity string) {
+	fingerprint, err := bootstrapper.FingerprintPEM(peerIdentity)
+	if err != nil {
+		log.Error(err, "Unable to compute this cluster's own identity fingerprint")
+		return
+	}
+
+	name := lastIdentityConfigMapPrefix + clusterId
+	cm, err := localClient.CoreV1().ConfigMaps(namespace).Get(name, metav1.GetOptions{})
+	switch {
+	case err == nil:
+		if cm.Data["fingerprint"] != fingerprint {
+			log.Info("this cluster's TLS identity fingerprint changed since the last broadcaster replica recorded one; " +
+				"if --cert-dir is not shared across replicas, a leader failover just rotated the identity published in " +
+				"Spec.PeerIdentity, and peers with a trust bundle pinned to the old fingerprint will reject this cluster " +
+				"until an operator re-pins it")
+		}
+		cm.Data = map[string]string{"fingerprint": fingerprint}
+		if _, err := localClient.CoreV1().ConfigMaps(namespace).Update(cm); err != nil {
+			log.Error(err, "Unable to record this cluster's current identity fingerprint")
+		}
+	case errors.IsNotFound(err):
+		_, err := localClient.CoreV1().ConfigMaps(namespace).Create(&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Data:       map[string]string{"fingerprint": fingerprint},
+		})
+		if err != nil && !errors.IsAlreadyExists(err) {
+			log.Error(err, "Unable to record this cluster's current identity fingerprint")
 		}
+	default:
+		log.Error(err, "Unable to read the previously recorded identity fingerprint")
 	}
+}
+
+// runBroadcaster watches the foreign-kubeconfig ConfigMaps through a shared informer and keeps
+// exactly one GenerateAdvertisement goroutine running per peer, so peers can be added, rotated
+// or removed without restarting the broadcaster; it returns as soon as ctx is cancelled, i.e.
+// when this instance stops being the leader
+func runBroadcaster(ctx context.Context, localClient *kubernetes.Clientset, localCRDClient client.Client, foreignKubeconfig string, clusterId string, peerIdentity string, gatewayIP string, gatewayPrivateIP string) {
+	// availability is identical for every peer, so it is computed once here and shared, instead
+	// of letting each peer's GenerateAdvertisement goroutine recompute it on its own tick.
+	// Named availCache, not cache, so it doesn't shadow the unaliased k8s.io/client-go/tools/cache
+	// import used below for cache.ResourceEventHandlerFuncs/cache.DeletedFinalStateUnknown.
+	availCache := newAvailabilityCache()
+	if err := availCache.refresh(localClient); err != nil {
+		log.Error(err, "Unable to compute initial cluster availability")
+	}
+	go runAvailabilityRefresher(ctx, localClient, availCache)
+
+	pm := newPeerManager(availCache, localCRDClient, foreignKubeconfig, clusterId, peerIdentity, gatewayIP, gatewayPrivateIP)
+
+	factory := informers.NewSharedInformerFactoryWithOptions(localClient, 0,
+		informers.WithNamespace("default"),
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.LabelSelector = foreignKubeconfigLabel + "=true"
+		}),
+	)
+	cmInformer := factory.Core().V1().ConfigMaps().Informer()
+	cmInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if cm, ok := obj.(*corev1.ConfigMap); ok {
+				pm.onAdd(ctx, cm.DeepCopy())
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			if cm, ok := newObj.(*corev1.ConfigMap); ok {
+				pm.onUpdate(ctx, cm.DeepCopy())
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			cm, ok := obj.(*corev1.ConfigMap)
+			if !ok {
+				tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+				if !ok {
+					return
+				}
+				cm, ok = tombstone.Obj.(*corev1.ConfigMap)
+				if !ok {
+					return
+				}
+			}
+			pm.onDelete(cm.DeepCopy())
+		},
+	})
 
-	wg.Wait()
+	factory.Start(ctx.Done())
+	factory.WaitForCacheSync(ctx.Done())
+
+	<-ctx.Done()
+	pm.stopAll()
 }
 
 // generate an advertisement message every 10 minutes and post it to remote clusters
 // parameters
-// - localClient: a client to the local kubernetes
+// - ctx: cancelled when the broadcaster loses leadership or receives SIGINT/SIGTERM; it stops
+//   this goroutine instead of letting it block in time.Sleep until the next tick
+// - cache: this cluster's availability, kept up to date by a single shared refresher goroutine
+//   instead of being recomputed here, since it is identical across every peer
 // - localCRDClient: a CRD client to the local kubernetes
 // - foreignKubeconfigPath: the path to a kubeconfig file. If set, this file is used to create a client to the foreign cluster. Set it only for debugging purposes
 // - cm: the configMap containing the kubeconfig to the foreign cluster. IMPORTANT: the data in the configMap must be named "remote"
-func GenerateAdvertisement(wg *sync.WaitGroup, localClient *kubernetes.Clientset, localCRDClient client.Client, foreignKubeconfigPath string, cm *v1.ConfigMap, clusterId string, gatewayIP string, gatewayPrivateIP string) {
+// - peerIdentity: the PEM-encoded public certificate identifying this cluster, published as
+//   Spec.PeerIdentity in every Advertisement this goroutine sends
+// ctx is also passed down into the per-tick CreateOrUpdate call, so a peer wedged in the remote
+// call no longer has to be waited out once stopLocked/stopAll cancel ctx
+func GenerateAdvertisement(ctx context.Context, wg *sync.WaitGroup, cache *availabilityCache, localCRDClient client.Client, foreignKubeconfigPath string, cm *corev1.ConfigMap, clusterId string, peerIdentity string, gatewayIP string, gatewayPrivateIP string) {
 	//TODO: recovering logic if errors occurs
 
 	var remoteClient client.Client
-	var err error
-	var retry int
 	var foreignClusterId string
 	var once sync.Once
 
@@ -92,32 +245,26 @@ func GenerateAdvertisement(wg *sync.WaitGroup, localClient *kubernetes.Clientset
 	if cm != nil {
 		foreignClusterId = cm.Name[len("foreign-kubeconfig-"):]
 	}
-	// create a CRDclient to the foreign cluster
-	for retry = 0; retry < 3; retry++ {
-		remoteClient, err = pkg.NewCRDClient(foreignKubeconfigPath, cm)
-		if err != nil {
-			log.Error(err, "Unable to create client to remote cluster "+foreignClusterId+". Retry in 1 minute")
-			time.Sleep(1 * time.Minute)
-		} else {
-			break
+	// create a CRDclient to the foreign cluster, backing off instead of tearing down the
+	// goroutine on transient peer connectivity blips
+	err := pkg.ExponentialBackoff(ctx, 3, 1*time.Minute, 5*time.Minute, func() error {
+		var backoffErr error
+		remoteClient, backoffErr = pkg.NewCRDClient(foreignKubeconfigPath, cm)
+		if backoffErr != nil {
+			log.Error(backoffErr, "Unable to create client to remote cluster "+foreignClusterId+", retrying")
 		}
-	}
-	if retry == 3 {
+		return backoffErr
+	})
+	if err != nil {
 		log.Error(err, "Failed to create client to remote cluster "+foreignClusterId)
 		return
-	} else {
-		log.Info("created client to remote cluster " + foreignClusterId)
 	}
+	log.Info("created client to remote cluster " + foreignClusterId)
 
 	for {
-		nodes, err := localClient.CoreV1().Nodes().List(metav1.ListOptions{LabelSelector: "type != virtual-node"})
-		if err != nil {
-			log.Error(err, "Unable to list nodes")
-			return
-		}
-
-		adv := CreateAdvertisement(nodes.Items, clusterId, gatewayIP, gatewayPrivateIP)
-		err = pkg.CreateOrUpdate(remoteClient, context.Background(), log, adv)
+		availability, images, podCIDR := cache.get()
+		adv := CreateAdvertisement(availability, images, podCIDR, clusterId, peerIdentity, gatewayIP, gatewayPrivateIP)
+		err := pkg.CreateOrUpdate(remoteClient, ctx, log, adv)
 		if err != nil {
 			log.Error(err, "Unable to create advertisement on remote cluster "+foreignClusterId)
 		} else {
@@ -130,15 +277,21 @@ func GenerateAdvertisement(wg *sync.WaitGroup, localClient *kubernetes.Clientset
 				WatchAdvertisement(localCRDClient, scheme, foreignKubeconfigPath, cm, clusterId, foreignClusterId)
 			})
 		}
-		time.Sleep(10 * time.Minute)
+
+		select {
+		case <-time.After(10 * time.Minute):
+		case <-ctx.Done():
+			log.Info("stopping advertisement broadcast to remote cluster " + foreignClusterId)
+			return
+		}
 	}
 }
 
-// create advertisement message
-func CreateAdvertisement(nodes []corev1.Node, clusterId string, gatewayIP string, gatewayPrivateIp string) protocolv1.Advertisement {
+// create advertisement message out of availability/images computed once per tick and shared
+// across every peer by the caller, rather than recomputed here
+func CreateAdvertisement(availability corev1.ResourceList, images []corev1.ContainerImage, podCIDR string, clusterId string, peerIdentity string, gatewayIP string, gatewayPrivateIp string) protocolv1.Advertisement {
 
-	availability, images := GetClusterResources(nodes)
-	prices := ComputePrices(images)
+	prices := ComputePrices(availability, images)
 
 	adv := protocolv1.Advertisement{
 		ObjectMeta: metav1.ObjectMeta{
@@ -147,14 +300,15 @@ func CreateAdvertisement(nodes []corev1.Node, clusterId string, gatewayIP string
 		},
 		Spec: protocolv1.AdvertisementSpec{
 			ClusterId:    clusterId,
+			PeerIdentity: peerIdentity,
 			Images:       images,
 			Availability: availability,
-			LimitRange: v1.LimitRangeSpec{
-				Limits: []v1.LimitRangeItem{},
+			LimitRange: corev1.LimitRangeSpec{
+				Limits: []corev1.LimitRangeItem{},
 			},
-			Prices:       prices,
+			Prices: prices,
 			Network: protocolv1.NetworkInfo{
-				PodCIDR:            GetPodCIDR(nodes),
+				PodCIDR:            podCIDR,
 				GatewayIP:          gatewayIP,
 				GatewayPrivateIP:   gatewayPrivateIp,
 				SupportedProtocols: nil,
@@ -211,55 +365,98 @@ func getPodsTotalRequestsAndLimits(podList *corev1.PodList) (reqs map[corev1.Res
 	return
 }
 
-func A(c *kubernetes.Clientset, namespace string, name string) (string, error){
+// getNodeUsedResources returns how much CPU, memory, ephemeral-storage and pods are currently
+// requested by the non-terminated pods scheduled on node name, across all namespaces
+func getNodeUsedResources(c *kubernetes.Clientset, name string) (corev1.ResourceList, error) {
 	fieldSelector, err := fields.ParseSelector("spec.nodeName=" + name + ",status.phase!=" + string(corev1.PodSucceeded) + ",status.phase!=" + string(corev1.PodFailed))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	nodeNonTerminatedPodsList, err := c.CoreV1().Pods(namespace).List(metav1.ListOptions{FieldSelector: fieldSelector.String()})
+	nodeNonTerminatedPodsList, err := c.CoreV1().Pods(metav1.NamespaceAll).List(metav1.ListOptions{FieldSelector: fieldSelector.String()})
 	if err != nil {
 		if !errors.IsForbidden(err) {
-			return "", err
+			return nil, err
 		}
-
+		return corev1.ResourceList{}, nil
 	}
-	reqs, limits := getPodsTotalRequestsAndLimits(nodeNonTerminatedPodsList)
-	cpuReqs, cpuLimits, memoryReqs, memoryLimits, ephemeralstorageReqs, ephemeralstorageLimits :=
-		reqs[corev1.ResourceCPU], limits[corev1.ResourceCPU], reqs[corev1.ResourceMemory], limits[corev1.ResourceMemory], reqs[corev1.ResourceEphemeralStorage], limits[corev1.ResourceEphemeralStorage]
 
+	reqs, _ := getPodsTotalRequestsAndLimits(nodeNonTerminatedPodsList)
+	used := corev1.ResourceList{
+		corev1.ResourceCPU:              reqs[corev1.ResourceCPU],
+		corev1.ResourceMemory:           reqs[corev1.ResourceMemory],
+		corev1.ResourceEphemeralStorage: reqs[corev1.ResourceEphemeralStorage],
+		corev1.ResourcePods:             *resource.NewQuantity(int64(len(nodeNonTerminatedPodsList.Items)), resource.DecimalSI),
+	}
+	return used, nil
 }
 
-// get cluster resources (cpu, ram and pods) and images
-func GetClusterResources(nodes []corev1.Node) (corev1.ResourceList, []corev1.ContainerImage) {
-	cpu := resource.Quantity{}
-	ram := resource.Quantity{}
-	pods := resource.Quantity{}
+// get cluster resources (cpu, ram, ephemeral-storage and pods) and images; availability is the
+// allocatable capacity of every node minus what is currently requested by pods on it, clamped at
+// zero, rather than the raw allocatable capacity
+func GetClusterResources(localClient *kubernetes.Clientset, nodes []corev1.Node) (corev1.ResourceList, []corev1.ContainerImage) {
+	availability := corev1.ResourceList{}
 	images := make([]corev1.ContainerImage, 0)
 
+	trackedResources := []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory, corev1.ResourceEphemeralStorage, corev1.ResourcePods}
 
 	for _, node := range nodes {
-		cpu.Add(*node.Status.Allocatable.Cpu())
-		ram.Add(*node.Status.Allocatable.Memory())
-		pods.Add(*node.Status.Allocatable.Pods())
+		used, err := getNodeUsedResources(localClient, node.Name)
+		if err != nil {
+			log.Error(err, "Unable to compute used resources for node "+node.Name)
+			used = corev1.ResourceList{}
+		}
+		publishNodeUsageMetrics(node.Name, used)
+
+		for _, resName := range trackedResources {
+			free := node.Status.Allocatable[resName].DeepCopy()
+			free.Sub(used[resName])
+			if free.Sign() < 0 {
+				free = resource.Quantity{}
+			}
+
+			total := availability[resName]
+			total.Add(free)
+			availability[resName] = total
+		}
 
 		//TODO: filter images
 		for _, image := range node.Status.Images {
 			images = append(images, image)
 		}
 	}
-	availability := corev1.ResourceList{}
-	availability[corev1.ResourceCPU] = cpu
-	availability[corev1.ResourceMemory] = ram
-	availability[corev1.ResourcePods] = pods
 	return availability, images
 }
 
-// create prices resource for advertisement
-func ComputePrices(images []corev1.ContainerImage) corev1.ResourceList {
-	//TODO: logic to set prices
+// CPUScarcityThreshold and MemoryScarcityThreshold are the amounts of cluster-wide free CPU and
+// memory below which ComputePrices starts charging a premium; they are package-level variables
+// rather than constants so they can be tuned per deployment
+var (
+	CPUScarcityThreshold    = resource.MustParse("4")
+	MemoryScarcityThreshold = resource.MustParse("8Gi")
+)
+
+// scarcityMultiplier grows quadratically from 1 (available >= threshold) towards 10 (nothing
+// available left) as available drops below threshold
+func scarcityMultiplier(available, threshold resource.Quantity) float64 {
+	if available.MilliValue() >= threshold.MilliValue() {
+		return 1
+	}
+	if available.Sign() <= 0 {
+		return 10
+	}
+	scarcity := 1 - float64(available.MilliValue())/float64(threshold.MilliValue())
+	return 1 + scarcity*scarcity*9
+}
+
+// create prices resource for advertisement: scarcer resources are priced higher, so that an
+// almost-full cluster naturally becomes a less attractive peer than an idle one
+func ComputePrices(availability corev1.ResourceList, images []corev1.ContainerImage) corev1.ResourceList {
+	cpuAvailable := availability[corev1.ResourceCPU]
+	memoryAvailable := availability[corev1.ResourceMemory]
+
 	prices := corev1.ResourceList{}
-	prices[corev1.ResourceCPU] = *resource.NewQuantity(1, resource.DecimalSI)
-	prices[corev1.ResourceMemory] = resource.MustParse("2Gi")
+	prices[corev1.ResourceCPU] = *resource.NewQuantity(int64(1*scarcityMultiplier(cpuAvailable, CPUScarcityThreshold)), resource.DecimalSI)
+	prices[corev1.ResourceMemory] = *resource.NewQuantity(int64(2*1024*1024*1024*scarcityMultiplier(memoryAvailable, MemoryScarcityThreshold)), resource.BinarySI)
 	for _, image := range images {
 		for _, name := range image.Names {
 			prices[corev1.ResourceName(name)] = *resource.NewQuantity(5, resource.DecimalSI)
@@ -0,0 +1,52 @@
+package advertisement_operator
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestScarcityMultiplier(t *testing.T) {
+	tests := []struct {
+		name      string
+		available string
+		threshold string
+		want      float64
+	}{
+		{"plenty available", "8", "4", 1},
+		{"exactly at threshold", "4", "4", 1},
+		{"nothing left", "0", "4", 10},
+		{"halfway to threshold", "2", "4", 1 + 0.5*0.5*9},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			available := resource.MustParse(tt.available)
+			threshold := resource.MustParse(tt.threshold)
+			if got := scarcityMultiplier(available, threshold); got != tt.want {
+				t.Errorf("scarcityMultiplier(%s, %s) = %v, want %v", tt.available, tt.threshold, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestComputePrices(t *testing.T) {
+	availability := corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse("8"),
+		corev1.ResourceMemory: resource.MustParse("16Gi"),
+	}
+	images := []corev1.ContainerImage{{Names: []string{"example.com/foo:latest"}}}
+
+	prices := ComputePrices(availability, images)
+
+	if got, want := prices[corev1.ResourceCPU], resource.MustParse("1"); got.Cmp(want) != 0 {
+		t.Errorf("cpu price = %s, want %s (no scarcity multiplier above threshold)", got.String(), want.String())
+	}
+	if got, want := prices[corev1.ResourceMemory], resource.MustParse("2Gi"); got.Cmp(want) != 0 {
+		t.Errorf("memory price = %s, want %s (no scarcity multiplier above threshold)", got.String(), want.String())
+	}
+	if _, ok := prices[corev1.ResourceName("example.com/foo:latest")]; !ok {
+		t.Errorf("ComputePrices did not price image %q", "example.com/foo:latest")
+	}
+}
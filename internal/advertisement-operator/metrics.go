@@ -0,0 +1,45 @@
+package advertisement_operator
+
+import (
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// per-node resource usage, as seen by the broadcaster while computing Advertisement.Spec.Availability;
+// these let an operator correlate the prices published in an Advertisement with the actual
+// occupancy of the nodes behind it
+var (
+	nodeCPUUsed = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "liqo_node_cpu_used",
+		Help: "CPU (millicores) requested by non-terminated pods on the node",
+	}, []string{"node"})
+	nodeMemoryUsed = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "liqo_node_memory_used",
+		Help: "Memory (bytes) requested by non-terminated pods on the node",
+	}, []string{"node"})
+	nodeEphemeralStorageUsed = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "liqo_node_ephemeral_storage_used",
+		Help: "Ephemeral storage (bytes) requested by non-terminated pods on the node",
+	}, []string{"node"})
+	nodePodsUsed = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "liqo_node_pods_used",
+		Help: "Number of non-terminated pods scheduled on the node",
+	}, []string{"node"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(nodeCPUUsed, nodeMemoryUsed, nodeEphemeralStorageUsed, nodePodsUsed)
+}
+
+// publishNodeUsageMetrics exposes used as the liqo_node_*_used gauges for node, so the prices
+// ComputePrices derives from the corresponding availability can be explained by an operator
+func publishNodeUsageMetrics(node string, used corev1.ResourceList) {
+	nodeCPUUsed.WithLabelValues(node).Set(float64(used.Cpu().MilliValue()))
+	nodeMemoryUsed.WithLabelValues(node).Set(float64(used.Memory().Value()))
+	if storage, ok := used[corev1.ResourceEphemeralStorage]; ok {
+		nodeEphemeralStorageUsed.WithLabelValues(node).Set(float64(storage.Value()))
+	}
+	nodePodsUsed.WithLabelValues(node).Set(float64(used.Pods().Value()))
+}
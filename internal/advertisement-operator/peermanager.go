@@ -0,0 +1,153 @@
+package advertisement_operator
+
+import (
+	"context"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	protocolv1 "github.com/netgroup-polito/dronev2/api/advertisement-operator/v1"
+	pkg "github.com/netgroup-polito/dronev2/pkg/advertisement-operator"
+)
+
+// peerHandle tracks the GenerateAdvertisement goroutine started for a single foreign-kubeconfig
+// ConfigMap, so it can be torn down again on update/delete
+type peerHandle struct {
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// peerManager keeps exactly one GenerateAdvertisement goroutine running per foreign-kubeconfig
+// ConfigMap, starting, restarting or stopping it in response to informer events instead of
+// requiring a pod restart whenever a peer cluster is added, rotated or removed
+type peerManager struct {
+	mutex sync.Mutex
+	peers map[string]*peerHandle // keyed by ConfigMap name
+
+	cache                 *availabilityCache
+	localCRDClient        client.Client
+	foreignKubeconfigPath string
+	clusterId             string
+	peerIdentity          string
+	gatewayIP             string
+	gatewayPrivateIP      string
+}
+
+func newPeerManager(cache *availabilityCache, localCRDClient client.Client, foreignKubeconfigPath string, clusterId string, peerIdentity string, gatewayIP string, gatewayPrivateIP string) *peerManager {
+	return &peerManager{
+		peers:                 make(map[string]*peerHandle),
+		cache:                 cache,
+		localCRDClient:        localCRDClient,
+		foreignKubeconfigPath: foreignKubeconfigPath,
+		clusterId:             clusterId,
+		peerIdentity:          peerIdentity,
+		gatewayIP:             gatewayIP,
+		gatewayPrivateIP:      gatewayPrivateIP,
+	}
+}
+
+// onAdd starts a new GenerateAdvertisement goroutine for cm, unless one is already running
+func (pm *peerManager) onAdd(parentCtx context.Context, cm *v1.ConfigMap) {
+	pm.mutex.Lock()
+	defer pm.mutex.Unlock()
+
+	if _, ok := pm.peers[cm.Name]; ok {
+		return
+	}
+	pm.start(parentCtx, cm)
+}
+
+// onUpdate handles kubeconfig rotation: it starts a new goroutine with the updated ConfigMap,
+// then cancels the one running with the stale kubeconfig and waits for it to exit
+func (pm *peerManager) onUpdate(parentCtx context.Context, cm *v1.ConfigMap) {
+	pm.mutex.Lock()
+	old := pm.popLocked(cm.Name)
+	pm.start(parentCtx, cm)
+	pm.mutex.Unlock()
+
+	old.stop()
+}
+
+// onDelete stops the goroutine for the removed peer and best-effort deletes the stale
+// Advertisement this cluster had published on that foreign cluster
+func (pm *peerManager) onDelete(cm *v1.ConfigMap) {
+	pm.mutex.Lock()
+	old := pm.popLocked(cm.Name)
+	pm.mutex.Unlock()
+
+	old.stop()
+	pm.deleteStaleAdvertisement(cm)
+}
+
+// stopAll cancels every running peer goroutine and waits for them to exit; used when the
+// broadcaster itself is shutting down, e.g. on leadership loss
+func (pm *peerManager) stopAll() {
+	pm.mutex.Lock()
+	handles := make([]*peerHandle, 0, len(pm.peers))
+	for name := range pm.peers {
+		handles = append(handles, pm.peers[name])
+	}
+	pm.peers = make(map[string]*peerHandle)
+	pm.mutex.Unlock()
+
+	// cancel every peer before waiting on any of them, so one slow/unreachable peer blocked in
+	// pkg.CreateOrUpdate's backoff doesn't delay the others from even seeing their cancellation
+	for _, handle := range handles {
+		handle.cancel()
+	}
+	for _, handle := range handles {
+		handle.wg.Wait()
+	}
+}
+
+// start must be called with pm.mutex held
+func (pm *peerManager) start(parentCtx context.Context, cm *v1.ConfigMap) {
+	peerCtx, cancel := context.WithCancel(parentCtx)
+	handle := &peerHandle{cancel: cancel}
+	handle.wg.Add(1)
+	pm.peers[cm.Name] = handle
+
+	go GenerateAdvertisement(peerCtx, &handle.wg, pm.cache, pm.localCRDClient, pm.foreignKubeconfigPath, cm, pm.clusterId, pm.peerIdentity, pm.gatewayIP, pm.gatewayPrivateIP)
+}
+
+// popLocked removes and returns the peer handle for name, if any, without cancelling or waiting
+// on it; it must be called with pm.mutex held. Splitting this out from the actual stop lets
+// callers release pm.mutex before blocking on handle.wg.Wait(), so one peer stuck in
+// pkg.CreateOrUpdate's multi-minute backoff can no longer stall onAdd/onUpdate/onDelete for
+// every other peer
+func (pm *peerManager) popLocked(name string) *peerHandle {
+	handle, ok := pm.peers[name]
+	if !ok {
+		return nil
+	}
+	delete(pm.peers, name)
+	return handle
+}
+
+// stop cancels handle's goroutine and waits for it to exit; handle may be nil
+func (handle *peerHandle) stop() {
+	if handle == nil {
+		return
+	}
+	handle.cancel()
+	handle.wg.Wait()
+}
+
+func (pm *peerManager) deleteStaleAdvertisement(cm *v1.ConfigMap) {
+	remoteClient, err := pkg.NewCRDClient(pm.foreignKubeconfigPath, cm)
+	if err != nil {
+		log.Error(err, "Unable to create client to remote cluster to delete stale advertisement")
+		return
+	}
+
+	adv := &protocolv1.Advertisement{ObjectMeta: metav1.ObjectMeta{
+		Name:      "advertisement-" + pm.clusterId,
+		Namespace: "default",
+	}}
+	if err := remoteClient.Delete(context.Background(), adv); err != nil && !errors.IsNotFound(err) {
+		log.Error(err, "best-effort delete of stale advertisement on removed peer failed")
+	}
+}
@@ -0,0 +1,60 @@
+package advertisement_operator
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	protocolv1 "github.com/netgroup-polito/dronev2/api/advertisement-operator/v1"
+	"github.com/netgroup-polito/dronev2/pkg/advertisement-operator/bootstrapper"
+)
+
+// trustBundleConfigMapPrefix names the ConfigMap, in the "default" namespace, that pins the
+// expected Spec.PeerIdentity fingerprint for a given cluster ID: "<prefix><clusterId>", with the
+// fingerprint itself under its "fingerprint" key
+const trustBundleConfigMapPrefix = "peer-trust-"
+
+// PeerIdentityValidator is a validating admission webhook that rejects incoming Advertisement
+// objects whose Spec.PeerIdentity fingerprint does not match the trust bundle registered for
+// that cluster ID, so a cluster operator can pin or rotate peer certificates independently of
+// whatever kubeconfig was used to reach the API server.
+// +kubebuilder:webhook:path=/validate-advertisement,mutating=false,failurePolicy=fail,groups=protocol.liqo.io,resources=advertisements,verbs=create;update,versions=v1,name=vadvertisement.liqo.io
+type PeerIdentityValidator struct {
+	Client  client.Client
+	decoder *admission.Decoder
+}
+
+func (v *PeerIdentityValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	adv := &protocolv1.Advertisement{}
+	if err := v.decoder.Decode(req, adv); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	fingerprint, err := bootstrapper.FingerprintPEM(adv.Spec.PeerIdentity)
+	if err != nil {
+		return admission.Denied(fmt.Sprintf("invalid PeerIdentity: %v", err))
+	}
+
+	trustBundle := &corev1.ConfigMap{}
+	trustBundleName := trustBundleConfigMapPrefix + adv.Spec.ClusterId
+	if err := v.Client.Get(ctx, client.ObjectKey{Namespace: "default", Name: trustBundleName}, trustBundle); err != nil {
+		return admission.Denied(fmt.Sprintf("no trust bundle %q registered for cluster %s: %v", trustBundleName, adv.Spec.ClusterId, err))
+	}
+
+	if trustBundle.Data["fingerprint"] != fingerprint {
+		return admission.Denied(fmt.Sprintf("PeerIdentity fingerprint for cluster %s does not match its registered trust bundle", adv.Spec.ClusterId))
+	}
+
+	return admission.Allowed("")
+}
+
+// InjectDecoder is called by the controller-runtime webhook server to give this validator a
+// decoder able to turn the incoming AdmissionRequest into an Advertisement
+func (v *PeerIdentityValidator) InjectDecoder(d *admission.Decoder) error {
+	v.decoder = d
+	return nil
+}
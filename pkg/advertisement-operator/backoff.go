@@ -0,0 +1,32 @@
+package advertisement_operator
+
+import (
+	"context"
+	"time"
+)
+
+// ExponentialBackoff retries fn, doubling the delay between attempts (starting at initialDelay
+// and capped at maxDelay), until fn succeeds, maxRetries attempts have been made, or ctx is
+// cancelled. It returns the error from the last attempt, or ctx.Err() if ctx was cancelled
+// while waiting for the next attempt.
+func ExponentialBackoff(ctx context.Context, maxRetries int, initialDelay time.Duration, maxDelay time.Duration, fn func() error) error {
+	delay := initialDelay
+	var err error
+	for attempt := 0; attempt < maxRetries; attempt++ {
+		if err = fn(); err == nil {
+			return nil
+		}
+		if attempt == maxRetries-1 {
+			break
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if delay *= 2; delay > maxDelay {
+			delay = maxDelay
+		}
+	}
+	return err
+}
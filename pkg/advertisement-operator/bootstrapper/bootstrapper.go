@@ -0,0 +1,52 @@
+// Package bootstrapper lets the advertisement broadcaster establish peering with a foreign
+// cluster on its own, instead of requiring an operator to hand-create a foreign-kubeconfig
+// ConfigMap before the broadcaster can start advertising to it.
+package bootstrapper
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ForeignKubeconfigLabel marks the ConfigMaps produced by Join as carrying a foreign cluster's
+// kubeconfig; the broadcaster's peer informer watches ConfigMaps selected by this label.
+const ForeignKubeconfigLabel = "liqo.io/foreign-kubeconfig"
+
+// PeerCandidate describes a foreign cluster that announced itself as available to peer with.
+type PeerCandidate struct {
+	// ClusterID uniquely identifies the candidate cluster (must be a UUID).
+	ClusterID string
+	// Endpoint is the candidate's Kubernetes API server address.
+	Endpoint string
+	// BootstrapToken is a short-lived credential presented to Endpoint to complete Join; it is
+	// not the credential that ends up in the resulting kubeconfig.
+	BootstrapToken string
+	// ServerCertificateFingerprint is the hex-encoded SHA-256 fingerprint, carried out of band
+	// (the static peers Secret, an mDNS TXT record, ...), that Endpoint's TLS certificate must
+	// match before Join trusts it; the candidate's certificate is always self-signed, so this
+	// pinning stands in for the system trust store.
+	ServerCertificateFingerprint string
+}
+
+// Bootstrapper discovers candidate peer clusters and turns a chosen candidate into a
+// foreign-kubeconfig ConfigMap the broadcaster's peer informer can pick up, without requiring a
+// cluster-admin kubeconfig to be shipped around by hand.
+type Bootstrapper interface {
+	// Discover returns the peer clusters currently available to join.
+	Discover(ctx context.Context) ([]PeerCandidate, error)
+	// Join establishes peering with candidate: it creates a dedicated, minimally-privileged
+	// ServiceAccount on the foreign cluster and returns the foreign-kubeconfig ConfigMap
+	// materializing the resulting credentials, labeled with ForeignKubeconfigLabel.
+	Join(ctx context.Context, candidate PeerCandidate) (*corev1.ConfigMap, error)
+	// Leave tears down the peering previously established with clusterID, best-effort.
+	Leave(ctx context.Context, clusterID string) error
+}
+
+// Announcer is implemented by Bootstrappers that can also publish this cluster's own presence,
+// so that two clusters running the same discovery mechanism (e.g. two MDNS bootstrappers on the
+// same LAN) can find each other symmetrically instead of only ever discovering one-directionally.
+type Announcer interface {
+	// Announce publishes this cluster's own bootstrap service; it blocks until ctx is cancelled.
+	Announce(ctx context.Context, clusterID string, bootstrapToken string, certFingerprint string, port int) error
+}
@@ -0,0 +1,20 @@
+package bootstrapper
+
+import (
+	"crypto/sha256"
+	"encoding/pem"
+	"fmt"
+)
+
+// FingerprintPEM returns the hex-encoded SHA-256 fingerprint of the first certificate found in
+// certPEM, in the same format used to pin ServerCertificateFingerprint on a PeerCandidate.
+// Exported so callers outside this package (e.g. the broadcaster's mDNS Announce wiring) can
+// compute the fingerprint of their own certificate the exact same way a peer would.
+func FingerprintPEM(certPEM string) (string, error) {
+	block, _ := pem.Decode([]byte(certPEM))
+	if block == nil {
+		return "", fmt.Errorf("no PEM block found")
+	}
+	sum := sha256.Sum256(block.Bytes)
+	return fmt.Sprintf("%x", sum), nil
+}
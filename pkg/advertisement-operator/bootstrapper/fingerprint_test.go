@@ -0,0 +1,46 @@
+package bootstrapper
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestFingerprintPEM(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("unable to generate key: %v", err)
+	}
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test-cluster"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().AddDate(1, 0, 0),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("unable to create certificate: %v", err)
+	}
+	certPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	want := fmt.Sprintf("%x", sha256.Sum256(der))
+
+	got, err := FingerprintPEM(certPEM)
+	if err != nil {
+		t.Fatalf("FingerprintPEM returned an error: %v", err)
+	}
+	if got != want {
+		t.Errorf("FingerprintPEM() = %s, want %s", got, want)
+	}
+
+	if _, err := FingerprintPEM("not a certificate"); err == nil {
+		t.Error("FingerprintPEM() with invalid PEM should have returned an error")
+	}
+}
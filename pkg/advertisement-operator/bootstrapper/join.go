@@ -0,0 +1,227 @@
+package bootstrapper
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"net/url"
+	"regexp"
+	"text/template"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// advertisementBootstrapServiceAccount prefixes the ServiceAccount created on the foreign
+// cluster for a joining peer.
+const advertisementBootstrapServiceAccount = "liqo-advertisement-bootstrap"
+
+// uuidPattern matches a clusterID in the documented format ("the cluster ID of this cluster (must
+// be a UUID)"). clusterID ends up, unescaped, inside the YAML kubeconfig buildKubeconfig renders
+// via text/template, so it must be validated before it reaches that template: a candidate
+// discovered via mDNS carries a clusterID read verbatim off an attacker-controlled TXT record
+// (parseCandidate in mdns.go), and without this check a crafted value containing a newline could
+// break out of the tls-server-name scalar and inject arbitrary keys into the generated kubeconfig.
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// joinWithBearerToken is shared by every Bootstrapper implementation: it pins apiServer's TLS
+// certificate against expectedFingerprint, connects using bootstrapToken, creates a
+// ServiceAccount scoped to get/list/create on Advertisement CRs instead of requiring a
+// cluster-admin kubeconfig, and returns a foreign-kubeconfig ConfigMap wrapping a kubeconfig
+// built from that ServiceAccount's own token.
+func joinWithBearerToken(ctx context.Context, apiServer string, bootstrapToken string, clusterID string, expectedFingerprint string) (*corev1.ConfigMap, error) {
+	if !uuidPattern.MatchString(clusterID) {
+		return nil, fmt.Errorf("candidate cluster ID %q is not a UUID", clusterID)
+	}
+
+	peerCertPEM, err := fetchAndVerifyPeerCertificate(ctx, apiServer, expectedFingerprint)
+	if err != nil {
+		return nil, err
+	}
+	tlsClientConfig := rest.TLSClientConfig{CAData: peerCertPEM, ServerName: clusterID}
+
+	bootstrapClient, err := kubernetes.NewForConfig(&rest.Config{Host: apiServer, BearerToken: bootstrapToken, TLSClientConfig: tlsClientConfig})
+	if err != nil {
+		return nil, fmt.Errorf("unable to build bootstrap client for %s: %w", apiServer, err)
+	}
+
+	sa, err := createMinimalServiceAccount(bootstrapClient, clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := waitForServiceAccountToken(bootstrapClient, sa)
+	if err != nil {
+		return nil, err
+	}
+
+	kubeconfig, err := buildKubeconfig(apiServer, token, peerCertPEM, clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "foreign-kubeconfig-" + clusterID,
+			Namespace: "default",
+			Labels:    map[string]string{ForeignKubeconfigLabel: "true"},
+		},
+		Data: map[string]string{"remote": kubeconfig},
+	}, nil
+}
+
+// fetchAndVerifyPeerCertificate dials apiServer's TLS endpoint, checks the fingerprint of the
+// certificate it presents against expectedFingerprint, and returns it PEM-encoded so the caller
+// can pin it for both the bootstrap client and the resulting kubeconfig. The system trust store
+// is never consulted: every peer's certificate is self-signed with its cluster ID as its only
+// SAN, so pinning the fingerprint carried out of band is the only way to authenticate it.
+func fetchAndVerifyPeerCertificate(ctx context.Context, apiServer string, expectedFingerprint string) ([]byte, error) {
+	u, err := url.Parse(apiServer)
+	if err != nil {
+		return nil, fmt.Errorf("invalid API server address %s: %w", apiServer, err)
+	}
+
+	dialer := &tls.Dialer{Config: &tls.Config{InsecureSkipVerify: true}}
+	conn, err := dialer.DialContext(ctx, "tcp", u.Host)
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to %s to fetch its certificate: %w", apiServer, err)
+	}
+	defer conn.Close()
+
+	peerCerts := conn.(*tls.Conn).ConnectionState().PeerCertificates
+	if len(peerCerts) == 0 {
+		return nil, fmt.Errorf("%s presented no TLS certificate", apiServer)
+	}
+
+	leaf := peerCerts[0]
+	fingerprint := fmt.Sprintf("%x", sha256.Sum256(leaf.Raw))
+	if expectedFingerprint == "" || fingerprint != expectedFingerprint {
+		return nil, fmt.Errorf("certificate presented by %s does not match its expected fingerprint: got %s, want %s", apiServer, fingerprint, expectedFingerprint)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leaf.Raw}), nil
+}
+
+// createMinimalServiceAccount get-or-creates a ServiceAccount, plus a Role/RoleBinding limited to
+// get/list/create on Advertisement CRs, scoped to this one peer. It is idempotent, since a
+// candidate already joined on a previous tick (or a previous process lifetime, after the
+// in-memory "already joined" bookkeeping in runBootstrapLoop was reset by a restart) is rejoined
+// with the same name rather than failing forever with AlreadyExists.
+func createMinimalServiceAccount(c kubernetes.Interface, clusterID string) (*corev1.ServiceAccount, error) {
+	name := advertisementBootstrapServiceAccount + "-" + clusterID
+
+	sa, err := c.CoreV1().ServiceAccounts("default").Create(&corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+	})
+	if apierrors.IsAlreadyExists(err) {
+		sa, err = c.CoreV1().ServiceAccounts("default").Get(name, metav1.GetOptions{})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to get or create service account %s: %w", name, err)
+	}
+
+	role, err := c.RbacV1().Roles("default").Create(&rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Rules: []rbacv1.PolicyRule{{
+			APIGroups: []string{"protocol.liqo.io"},
+			Resources: []string{"advertisements"},
+			Verbs:     []string{"get", "list", "create"},
+		}},
+	})
+	if apierrors.IsAlreadyExists(err) {
+		role, err = c.RbacV1().Roles("default").Get(name, metav1.GetOptions{})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to get or create role %s: %w", name, err)
+	}
+
+	if _, err := c.RbacV1().RoleBindings("default").Create(&rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Name: sa.Name, Namespace: "default"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "Role", Name: role.Name, APIGroup: "rbac.authorization.k8s.io"},
+	}); err != nil && !apierrors.IsAlreadyExists(err) {
+		return nil, fmt.Errorf("unable to create role binding %s: %w", name, err)
+	}
+
+	return sa, nil
+}
+
+// waitForServiceAccountToken polls for the ServiceAccount's auto-generated token Secret; the
+// client-go version vendored here predates TokenRequest, so it relies on the legacy
+// secret-based service account token instead.
+func waitForServiceAccountToken(c kubernetes.Interface, sa *corev1.ServiceAccount) (string, error) {
+	var token string
+	err := wait.PollImmediate(time.Second, 30*time.Second, func() (bool, error) {
+		current, err := c.CoreV1().ServiceAccounts(sa.Namespace).Get(sa.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		if len(current.Secrets) == 0 {
+			return false, nil
+		}
+		secret, err := c.CoreV1().Secrets(sa.Namespace).Get(current.Secrets[0].Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		if len(secret.Data["token"]) == 0 {
+			return false, nil
+		}
+		token = string(secret.Data["token"])
+		return true, nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("timed out waiting for a token for service account %s/%s: %w", sa.Namespace, sa.Name, err)
+	}
+	return token, nil
+}
+
+const kubeconfigTemplate = `apiVersion: v1
+kind: Config
+clusters:
+- name: foreign
+  cluster:
+    server: {{ .Server }}
+    certificate-authority-data: {{ .CAData }}
+    tls-server-name: {{ .ServerName }}
+contexts:
+- name: foreign
+  context:
+    cluster: foreign
+    user: foreign
+current-context: foreign
+users:
+- name: foreign
+  user:
+    token: {{ .Token }}
+`
+
+// buildKubeconfig pins the foreign cluster's self-signed certificate (caPEM) via
+// certificate-authority-data, and sets tls-server-name to serverName since that certificate's
+// only SAN is the cluster ID, not the network address in server.
+func buildKubeconfig(server string, token string, caPEM []byte, serverName string) (string, error) {
+	tmpl, err := template.New("kubeconfig").Parse(kubeconfigTemplate)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	data := struct{ Server, Token, CAData, ServerName string }{
+		Server:     server,
+		Token:      token,
+		CAData:     base64.StdEncoding.EncodeToString(caPEM),
+		ServerName: serverName,
+	}
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,111 @@
+package bootstrapper
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/grandcat/zeroconf"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// mdnsServiceType is the DNS-SD service type clusters announce themselves under.
+const mdnsServiceType = "_liqo-advertisement._tcp"
+
+// MDNS is a Bootstrapper that discovers peers on the local LAN via mDNS/DNS-SD, for
+// environments (edge, on-prem demos) without a central peer registry to read from.
+type MDNS struct {
+	// Domain is the mDNS domain to browse/announce on, e.g. "local."
+	Domain string
+	// BrowseTimeout bounds how long Discover waits for replies.
+	BrowseTimeout time.Duration
+}
+
+// NewMDNS builds an MDNS bootstrapper browsing/announcing on domain, waiting up to
+// browseTimeout for replies on Discover.
+func NewMDNS(domain string, browseTimeout time.Duration) *MDNS {
+	return &MDNS{Domain: domain, BrowseTimeout: browseTimeout}
+}
+
+// Discover browses for other liqo-advertisement._tcp instances on the LAN; the TXT record of
+// each reply is expected to carry "clusterId=<uuid>", "token=<bootstrap token>" and
+// "fingerprint=<certificate fingerprint>".
+func (m *MDNS) Discover(ctx context.Context) ([]PeerCandidate, error) {
+	resolver, err := zeroconf.NewResolver(nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to initialize mDNS resolver: %w", err)
+	}
+
+	entries := make(chan *zeroconf.ServiceEntry)
+	var candidates []PeerCandidate
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for entry := range entries {
+			if candidate, ok := parseCandidate(entry); ok {
+				candidates = append(candidates, candidate)
+			}
+		}
+	}()
+
+	browseCtx, cancel := context.WithTimeout(ctx, m.BrowseTimeout)
+	defer cancel()
+	if err := resolver.Browse(browseCtx, mdnsServiceType, m.Domain, entries); err != nil {
+		return nil, fmt.Errorf("unable to browse for peers: %w", err)
+	}
+	<-browseCtx.Done()
+	<-done
+
+	return candidates, nil
+}
+
+// Announce publishes this cluster's own bootstrap service so peers can discover it in turn; it
+// blocks until ctx is cancelled. certFingerprint is the hex-encoded SHA-256 fingerprint of this
+// cluster's own TLS certificate, published so a discovering peer can pin it on Join.
+func (m *MDNS) Announce(ctx context.Context, clusterID string, bootstrapToken string, certFingerprint string, port int) error {
+	server, err := zeroconf.Register(clusterID, mdnsServiceType, m.Domain, port,
+		[]string{"clusterId=" + clusterID, "token=" + bootstrapToken, "fingerprint=" + certFingerprint}, nil)
+	if err != nil {
+		return fmt.Errorf("unable to announce on mDNS: %w", err)
+	}
+	defer server.Shutdown()
+
+	<-ctx.Done()
+	return nil
+}
+
+func (m *MDNS) Join(ctx context.Context, candidate PeerCandidate) (*corev1.ConfigMap, error) {
+	return joinWithBearerToken(ctx, candidate.Endpoint, candidate.BootstrapToken, candidate.ClusterID, candidate.ServerCertificateFingerprint)
+}
+
+func (m *MDNS) Leave(ctx context.Context, clusterID string) error {
+	//TODO: announce departure on mDNS so peers can drop us proactively instead of waiting for the TTL to expire
+	return nil
+}
+
+func parseCandidate(entry *zeroconf.ServiceEntry) (PeerCandidate, bool) {
+	if len(entry.AddrIPv4) == 0 {
+		return PeerCandidate{}, false
+	}
+
+	fields := map[string]string{}
+	for _, txt := range entry.Text {
+		parts := strings.SplitN(txt, "=", 2)
+		if len(parts) == 2 {
+			fields[parts[0]] = parts[1]
+		}
+	}
+
+	clusterID, ok := fields["clusterId"]
+	if !ok {
+		return PeerCandidate{}, false
+	}
+
+	return PeerCandidate{
+		ClusterID:                    clusterID,
+		Endpoint:                     fmt.Sprintf("https://%s:%d", entry.AddrIPv4[0], entry.Port),
+		BootstrapToken:               fields["token"],
+		ServerCertificateFingerprint: fields["fingerprint"],
+	}, true
+}
@@ -0,0 +1,71 @@
+package bootstrapper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// staticPeer is one entry of the peers list stored in the configured Secret.
+type staticPeer struct {
+	ClusterID string `json:"clusterId"`
+	APIServer string `json:"apiServer"`
+	Token     string `json:"token"`
+	// CAData is the peer's PEM-encoded, self-signed TLS certificate, used to pin its identity
+	// instead of trusting whatever certificate apiServer happens to present.
+	CAData string `json:"caData"`
+}
+
+// Static is a Bootstrapper whose candidate peers are pre-provisioned out of band: an operator
+// lists, in a Secret, one {clusterId, apiServer, token, caData} entry per foreign cluster willing to
+// peer, instead of building and shipping a full admin kubeconfig for each of them.
+type Static struct {
+	Client     kubernetes.Interface
+	Namespace  string
+	SecretName string
+}
+
+// NewStatic builds a Static bootstrapper reading its peers from Namespace/SecretName.
+func NewStatic(client kubernetes.Interface, namespace string, secretName string) *Static {
+	return &Static{Client: client, Namespace: namespace, SecretName: secretName}
+}
+
+func (s *Static) Discover(ctx context.Context) ([]PeerCandidate, error) {
+	secret, err := s.Client.CoreV1().Secrets(s.Namespace).Get(s.SecretName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to read peers secret %s/%s: %w", s.Namespace, s.SecretName, err)
+	}
+
+	var peers []staticPeer
+	if err := json.Unmarshal(secret.Data["peers"], &peers); err != nil {
+		return nil, fmt.Errorf("unable to parse peers in secret %s/%s: %w", s.Namespace, s.SecretName, err)
+	}
+
+	candidates := make([]PeerCandidate, 0, len(peers))
+	for _, peer := range peers {
+		fingerprint, err := FingerprintPEM(peer.CAData)
+		if err != nil {
+			return nil, fmt.Errorf("invalid certificate for peer %s in secret %s/%s: %w", peer.ClusterID, s.Namespace, s.SecretName, err)
+		}
+		candidates = append(candidates, PeerCandidate{
+			ClusterID:                    peer.ClusterID,
+			Endpoint:                     peer.APIServer,
+			BootstrapToken:               peer.Token,
+			ServerCertificateFingerprint: fingerprint,
+		})
+	}
+	return candidates, nil
+}
+
+func (s *Static) Join(ctx context.Context, candidate PeerCandidate) (*corev1.ConfigMap, error) {
+	return joinWithBearerToken(ctx, candidate.Endpoint, candidate.BootstrapToken, candidate.ClusterID, candidate.ServerCertificateFingerprint)
+}
+
+func (s *Static) Leave(ctx context.Context, clusterID string) error {
+	// peers are managed out of band via the Secret, nothing to tear down here
+	return nil
+}
@@ -0,0 +1,123 @@
+package leaderelection
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// Config holds the parameters needed to run a callback under leader election,
+// backed by a Lease object in a (configurable) namespace.
+type Config struct {
+	// LeaseNamespace is the namespace in which the Lease used for the election is stored.
+	LeaseNamespace string
+	// LeaseName is the name of the Lease used for the election.
+	LeaseName string
+	// Identity uniquely identifies this instance among the candidates (e.g. the pod name).
+	Identity string
+	// Client is used to read/write the Lease object.
+	Client kubernetes.Interface
+	Log    logr.Logger
+}
+
+// Run blocks running leader election against a Lease-based resource lock: onStartedLeading
+// is invoked (with a context cancelled as soon as leadership is lost or the process receives
+// SIGINT/SIGTERM) while this instance is the leader, onStoppedLeading right after it stops
+// being the leader. On SIGINT/SIGTERM, since this codebase's client-go does not support
+// LeaderElectionConfig.ReleaseOnCancel, the Lease is released by hand: its holderIdentity is
+// cleared and its leaseDurationSeconds lowered to 1, so a standby instance can take over in
+// seconds rather than waiting out the full lease duration.
+func Run(cfg Config, onStartedLeading func(ctx context.Context), onStoppedLeading func()) error {
+	lock, err := resourcelock.New(
+		resourcelock.LeasesResourceLock,
+		cfg.LeaseNamespace,
+		cfg.LeaseName,
+		cfg.Client.CoreV1(),
+		cfg.Client.CoordinationV1(),
+		resourcelock.ResourceLockConfig{Identity: cfg.Identity},
+	)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sig
+		cfg.Log.Info("received termination signal, releasing lease " + cfg.LeaseNamespace + "/" + cfg.LeaseName)
+		releaseLease(cfg)
+		cancel()
+	}()
+
+	leaderelection.RunOrDie(ctx, leaderelection.LeaderElectionConfig{
+		Lock:          lock,
+		LeaseDuration: 15 * time.Second,
+		RenewDeadline: 10 * time.Second,
+		RetryPeriod:   2 * time.Second,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: onStartedLeading,
+			OnStoppedLeading: onStoppedLeading,
+			OnNewLeader: func(identity string) {
+				if identity != cfg.Identity {
+					cfg.Log.Info("a new leader for " + cfg.LeaseName + " has been elected: " + identity)
+				}
+			},
+		},
+	})
+
+	return nil
+}
+
+// releaseLease emulates a graceful lease release: it does not expose ReleaseOnCancel, so instead
+// it patches holderIdentity to empty and leaseDurationSeconds to 1 directly on the Lease object,
+// provided this instance is still the recorded holder.
+func releaseLease(cfg Config) {
+	lease, err := cfg.Client.CoordinationV1().Leases(cfg.LeaseNamespace).Get(cfg.LeaseName, metav1.GetOptions{})
+	if err != nil {
+		cfg.Log.Error(err, "unable to fetch lease "+cfg.LeaseNamespace+"/"+cfg.LeaseName+" for graceful release")
+		return
+	}
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity != cfg.Identity {
+		// lease has already moved on to someone else, nothing to release
+		return
+	}
+
+	emptyIdentity := ""
+	shortDuration := int32(1)
+	lease.Spec.HolderIdentity = &emptyIdentity
+	lease.Spec.LeaseDurationSeconds = &shortDuration
+
+	if _, err := cfg.Client.CoordinationV1().Leases(cfg.LeaseNamespace).Update(lease); err != nil {
+		cfg.Log.Error(err, "unable to patch lease "+cfg.LeaseNamespace+"/"+cfg.LeaseName+" for graceful release")
+		return
+	}
+
+	verifyLeaseReleased(cfg)
+}
+
+// verifyLeaseReleased re-reads the lease right after releaseLease patched it. client-go's own
+// renew loop keeps running until ctx is cancelled on its next iteration, so a renew already in
+// flight when the termination signal arrived can still land after our patch and silently revert
+// holderIdentity back to cfg.Identity; without ReleaseOnCancel (unavailable in this client-go)
+// we cannot prevent that race, so at least detect and log it instead of assuming the patch won.
+func verifyLeaseReleased(cfg Config) {
+	lease, err := cfg.Client.CoordinationV1().Leases(cfg.LeaseNamespace).Get(cfg.LeaseName, metav1.GetOptions{})
+	if err != nil {
+		cfg.Log.Error(err, "unable to verify graceful release of lease "+cfg.LeaseNamespace+"/"+cfg.LeaseName)
+		return
+	}
+	if lease.Spec.HolderIdentity != nil && *lease.Spec.HolderIdentity == cfg.Identity {
+		cfg.Log.Info("lease " + cfg.LeaseNamespace + "/" + cfg.LeaseName + " still names this instance as holder right after a graceful release; an in-flight renew likely raced and won, so a standby may not take over until the lease expires naturally")
+	}
+}
@@ -0,0 +1,135 @@
+package advertisement_operator
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DefaultCertDir is where InitializeTLS persists a self-generated keypair when --cert-dir is
+// left unset, mirroring kubelet's own default for its self-signed serving certificate.
+const DefaultCertDir = "/var/lib/liqo/pki"
+
+// InitializeTLS returns the TLS material the broadcaster uses to prove its identity to peers,
+// together with the PEM-encoded certificate alone (no key), meant to be published in
+// Advertisement.Spec.PeerIdentity.
+//
+// The files are those ResolveCertPaths picks: certFile and keyFile if both are set, otherwise
+// default names under certDir (DefaultCertDir if empty). If they already exist, they are loaded
+// as-is. Otherwise, modeled on kubelet's self-signed bootstrap flow, an ECDSA keypair and a
+// self-signed certificate whose SAN is clusterID are generated and persisted at those same paths,
+// so that subsequent restarts reuse the same identity instead of rotating it, and therefore
+// invalidating any trust bundle pinned to it, on every start.
+//
+// Every replica calls InitializeTLS on start, but only the identity of whichever one currently
+// holds broadcaster leadership is published. In an HA deployment with several replicas, certDir MUST point at storage shared
+// across all of them (e.g. a ReadWriteMany volume); left at its pod-local default, a leader
+// failover to a standby replica generates a brand new identity instead of reusing the previous
+// leader's, which every peer's trust bundle is still pinned to.
+func InitializeTLS(certDir string, certFile string, keyFile string, clusterID string) (tls.Certificate, []byte, error) {
+	certFile, keyFile = ResolveCertPaths(certDir, certFile, keyFile)
+
+	if cert, certPEM, err := loadKeyPair(certFile, keyFile); err == nil {
+		return cert, certPEM, nil
+	}
+
+	certPEM, keyPEM, err := generateSelfSignedECDSACert(clusterID)
+	if err != nil {
+		return tls.Certificate{}, nil, fmt.Errorf("unable to generate self-signed certificate: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(certFile), 0700); err != nil {
+		return tls.Certificate{}, nil, fmt.Errorf("unable to create cert dir %s: %w", filepath.Dir(certFile), err)
+	}
+	if err := os.WriteFile(certFile, certPEM, 0644); err != nil {
+		return tls.Certificate{}, nil, fmt.Errorf("unable to persist certificate to %s: %w", certFile, err)
+	}
+	if err := os.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		return tls.Certificate{}, nil, fmt.Errorf("unable to persist private key to %s: %w", keyFile, err)
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	return cert, certPEM, err
+}
+
+// ResolveCertPaths returns the certificate/key file paths InitializeTLS will load from or persist
+// to for the given flags, without touching the filesystem: certFile/keyFile as given if both are
+// set, otherwise the default file names under certDir (DefaultCertDir if certDir is empty).
+// Exported so callers that need to point something else (e.g. a webhook server's CertDir) at the
+// same keypair don't have to re-derive this logic themselves.
+func ResolveCertPaths(certDir string, certFile string, keyFile string) (string, string) {
+	if certFile != "" && keyFile != "" {
+		return certFile, keyFile
+	}
+
+	if certDir == "" {
+		certDir = DefaultCertDir
+	}
+	if certFile == "" {
+		certFile = filepath.Join(certDir, "advertisement-broadcaster.crt")
+	}
+	if keyFile == "" {
+		keyFile = filepath.Join(certDir, "advertisement-broadcaster.key")
+	}
+	return certFile, keyFile
+}
+
+func loadKeyPair(certFile string, keyFile string) (tls.Certificate, []byte, error) {
+	certPEM, err := os.ReadFile(certFile)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	keyPEM, err := os.ReadFile(keyFile)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	return cert, certPEM, err
+}
+
+func generateSelfSignedECDSACert(clusterID string) (certPEM []byte, keyPEM []byte, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: clusterID},
+		DNSNames:              []string{clusterID},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}